@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/linkerd/linkerd2/pkg/healthcheck"
+	"github.com/spf13/cobra"
+)
+
+var stderr = os.Stderr
+
+// Output format flags accepted by `linkerd check -o`.
+const (
+	tableOutput = healthcheck.TableOutput
+	jsonOutput  = healthcheck.JSONOutput
+	junitOutput = healthcheck.JUnitOutput
+	sarifOutput = healthcheck.SarifOutput
+)
+
+type checkOptions struct {
+	output string
+}
+
+func newCheckOptions() *checkOptions {
+	return &checkOptions{output: tableOutput}
+}
+
+func newCmdCheck() *cobra.Command {
+	options := newCheckOptions()
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check the Linkerd installation for potential problems",
+		Long: `Check the Linkerd installation for potential problems.
+
+The check command will perform a series of checks to validate that the
+control plane is configured correctly. If a check fails, it will output
+instructions on how to resolve the problem.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hc := healthcheck.NewHealthChecker([]healthcheck.CategoryID{}, &healthcheck.Options{})
+			success := healthcheck.RunChecks(os.Stdout, stderr, hc, options.output)
+			if !success {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&options.output, "output", "o", options.output,
+		"Output format. One of: table, json, junit, sarif")
+
+	return cmd
+}
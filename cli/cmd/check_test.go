@@ -3,6 +3,7 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"testing"
@@ -10,6 +11,26 @@ import (
 	"github.com/linkerd/linkerd2/pkg/healthcheck"
 )
 
+// addCheckStatusFixtures registers an ok check, an ordinary failure, a
+// panicking check, and a check that times out, on hc. A panic and a timeout
+// must both serialize as errored rather than failed, distinctly from an
+// ordinary failure, so CI systems can tell "the check ran and failed" apart
+// from "the check itself broke".
+func addCheckStatusFixtures(hc *healthcheck.HealthChecker) {
+	hc.Add("category", "check-ok", "", func(context.Context) error {
+		return nil
+	})
+	hc.Add("category", "check-fail", "fail-hint", func(context.Context) error {
+		return errors.New("ordinary failure")
+	})
+	hc.Add("category", "check-panic", "panic-hint", func(context.Context) error {
+		panic("boom")
+	})
+	hc.Add("category", "check-timeout", "timeout-hint", func(context.Context) error {
+		return context.DeadlineExceeded
+	})
+}
+
 func TestCheckStatus(t *testing.T) {
 	t.Run("Prints expected output", func(t *testing.T) {
 		hc := healthcheck.NewHealthChecker(
@@ -64,4 +85,102 @@ func TestCheckStatus(t *testing.T) {
 			t.Fatalf("Expected function to render:\n%s\bbut got:\n%s", expectedContent, output)
 		}
 	})
+
+	t.Run("Prints expected output in junit", func(t *testing.T) {
+		hc := healthcheck.NewHealthChecker(
+			[]healthcheck.CategoryID{},
+			&healthcheck.Options{},
+		)
+		hc.Add("category", "check1", "", func(context.Context) error {
+			return nil
+		})
+		hc.Add("category", "check2", "hint-anchor", func(context.Context) error {
+			return fmt.Errorf("This should contain instructions for fail")
+		})
+
+		output := bytes.NewBufferString("")
+		healthcheck.RunChecks(output, stderr, hc, junitOutput)
+
+		goldenFileBytes, err := ioutil.ReadFile("testdata/check_output_junit.golden")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedContent := string(goldenFileBytes)
+
+		if expectedContent != output.String() {
+			t.Fatalf("Expected function to render:\n%s\bbut got:\n%s", expectedContent, output)
+		}
+	})
+
+	t.Run("Prints expected output in sarif", func(t *testing.T) {
+		hc := healthcheck.NewHealthChecker(
+			[]healthcheck.CategoryID{},
+			&healthcheck.Options{},
+		)
+		hc.Add("category", "check1", "", func(context.Context) error {
+			return nil
+		})
+		hc.Add("category", "check2", "hint-anchor", func(context.Context) error {
+			return fmt.Errorf("This should contain instructions for fail")
+		})
+
+		output := bytes.NewBufferString("")
+		healthcheck.RunChecks(output, stderr, hc, sarifOutput)
+
+		goldenFileBytes, err := ioutil.ReadFile("testdata/check_output_sarif.golden")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedContent := string(goldenFileBytes)
+
+		if expectedContent != output.String() {
+			t.Fatalf("Expected function to render:\n%s\bbut got:\n%s", expectedContent, output)
+		}
+	})
+
+	t.Run("Serializes panicking and timed-out checks as errored in junit", func(t *testing.T) {
+		hc := healthcheck.NewHealthChecker(
+			[]healthcheck.CategoryID{},
+			&healthcheck.Options{},
+		)
+		addCheckStatusFixtures(hc)
+
+		output := bytes.NewBufferString("")
+		healthcheck.RunChecks(output, stderr, hc, junitOutput)
+
+		goldenFileBytes, err := ioutil.ReadFile("testdata/check_output_junit_errored.golden")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedContent := string(goldenFileBytes)
+
+		if expectedContent != output.String() {
+			t.Fatalf("Expected function to render:\n%s\bbut got:\n%s", expectedContent, output)
+		}
+	})
+
+	t.Run("Serializes panicking and timed-out checks as errored in sarif", func(t *testing.T) {
+		hc := healthcheck.NewHealthChecker(
+			[]healthcheck.CategoryID{},
+			&healthcheck.Options{},
+		)
+		addCheckStatusFixtures(hc)
+
+		output := bytes.NewBufferString("")
+		healthcheck.RunChecks(output, stderr, hc, sarifOutput)
+
+		goldenFileBytes, err := ioutil.ReadFile("testdata/check_output_sarif_errored.golden")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedContent := string(goldenFileBytes)
+
+		if expectedContent != output.String() {
+			t.Fatalf("Expected function to render:\n%s\bbut got:\n%s", expectedContent, output)
+		}
+	})
 }
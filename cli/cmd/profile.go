@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/linkerd/linkerd2/pkg/profiles"
+	"github.com/spf13/cobra"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayapiclient "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+)
+
+type profileOptions struct {
+	namespace     string
+	clusterDomain string
+	kubeconfig    string
+	kubeContext   string
+
+	tap         string
+	tapDuration time.Duration
+	routeLimit  int
+
+	consolidatePaths bool
+
+	inferTimeouts     bool
+	inferRetries      bool
+	failurePercentile float64
+	fourXXThreshold   float64
+	direction         string
+
+	fromIngress bool
+}
+
+func newProfileOptions() *profileOptions {
+	return &profileOptions{
+		namespace:     "default",
+		clusterDomain: "cluster.local",
+		tapDuration:   5 * time.Second,
+		routeLimit:    20,
+		direction:     string(profiles.DirectionInbound),
+	}
+}
+
+func newCmdProfile() *cobra.Command {
+	options := newProfileOptions()
+
+	cmd := &cobra.Command{
+		Use:   "profile [flags] (--tap resource | --from-ingress) name",
+		Short: "Generate a ServiceProfile for a service",
+		Long: `Generate a ServiceProfile for a service.
+
+This command taps a resource's live traffic and derives a ServiceProfile
+from the routes it observes. Pass --consolidate (or its alias
+--path-template) to group structurally similar paths, such as /users/123
+and /users/456, into a single templated route instead of emitting one per
+observed path.
+
+Pass --infer-timeouts to suggest each route's Timeout from its observed
+p99 latency, and --infer-retries to mark routes with a non-trivial
+failure ratio as retryable and suggest a profile-wide RetryBudget.
+
+Pass --from-ingress instead of --tap to generate the profile from the
+Ingress and HTTPRoute resources that already route traffic to the
+service, without having to observe any live traffic first.
+
+--direction selects which side of the tapped resource's traffic to
+profile: inbound (the default), outbound (what it calls downstream), or
+both, which writes two profiles as a "---"-separated YAML stream.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			if options.tap == "" && !options.fromIngress {
+				return fmt.Errorf("one of --tap or --from-ingress must be specified")
+			}
+			if options.tap != "" && options.fromIngress {
+				return fmt.Errorf("--tap and --from-ingress cannot be used together")
+			}
+
+			k8sAPI, err := k8s.NewAPI(options.kubeconfig, options.kubeContext, "", nil, 0)
+			if err != nil {
+				return err
+			}
+
+			if options.fromIngress {
+				return renderProfileFromIngress(cmd.Context(), k8sAPI, options, name, os.Stdout)
+			}
+
+			opts := profiles.TapProfileOptions{
+				TapDuration:        options.tapDuration,
+				RouteLimit:         options.routeLimit,
+				ConsolidatePaths:   options.consolidatePaths,
+				InferTimeouts:      options.inferTimeouts,
+				InferRetries:       options.inferRetries,
+				FailurePercentile:  options.failurePercentile,
+				FourXXFailureRatio: options.fourXXThreshold,
+				Direction:          profiles.TapDirection(options.direction),
+			}
+
+			return profiles.RenderTapOutputProfile(cmd.Context(), k8sAPI, options.tap, options.namespace, name, options.clusterDomain, opts, os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "Namespace of the target service")
+	cmd.Flags().StringVar(&options.clusterDomain, "cluster-domain", options.clusterDomain, "Cluster domain used to name the generated profile")
+	cmd.Flags().StringVar(&options.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use")
+	cmd.Flags().StringVar(&options.kubeContext, "context", "", "Name of the kubeconfig context to use")
+
+	cmd.Flags().StringVar(&options.tap, "tap", "", "Generate a profile by tapping the given resource, e.g. deploy/my-deploy")
+	cmd.Flags().DurationVar(&options.tapDuration, "tap-duration", options.tapDuration, "Duration to tap for")
+	cmd.Flags().IntVar(&options.routeLimit, "tap-route-limit", options.routeLimit, "Max number of routes to add to the profile")
+
+	cmd.Flags().BoolVar(&options.consolidatePaths, "consolidate", options.consolidatePaths, "Consolidate tapped paths that share a structural shape into templated routes, e.g. /users/{id}")
+	cmd.Flags().BoolVar(&options.consolidatePaths, "path-template", options.consolidatePaths, "Alias for --consolidate")
+
+	cmd.Flags().BoolVar(&options.inferTimeouts, "infer-timeouts", options.inferTimeouts, "Suggest each route's Timeout from its observed p99 latency")
+	cmd.Flags().BoolVar(&options.inferRetries, "infer-retries", options.inferRetries, "Mark routes with a non-trivial failure ratio as retryable and suggest a RetryBudget")
+	cmd.Flags().Float64Var(&options.failurePercentile, "failure-percentile", 0, "Latency percentile (0, 1] used by --infer-timeouts; defaults to 0.99")
+	cmd.Flags().Float64Var(&options.fourXXThreshold, "fourxx-failure-threshold", 0, "Minimum share of 4xx responses on a route before they're folded into failures too; defaults to 0.1")
+	cmd.Flags().StringVar(&options.direction, "direction", options.direction, "Traffic direction to tap and profile: inbound, outbound, or both")
+
+	cmd.Flags().BoolVar(&options.fromIngress, "from-ingress", false, "Generate a profile from the Ingress and HTTPRoute resources that route to this service, instead of tapping it")
+
+	return cmd
+}
+
+// renderProfileFromIngress lists the Ingress and HTTPRoute resources in
+// options.namespace and builds a ServiceProfile for service from whichever
+// of them route to it. The HTTPRoute list is best-effort: a cluster without
+// the Gateway API installed still produces a profile from Ingress alone.
+func renderProfileFromIngress(ctx context.Context, k8sAPI *k8s.KubernetesAPI, options *profileOptions, service string, w io.Writer) error {
+	ingressList, err := k8sAPI.NetworkingV1().Ingresses(options.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	ingresses := make([]*networkingv1.Ingress, 0, len(ingressList.Items))
+	for i := range ingressList.Items {
+		ingresses = append(ingresses, &ingressList.Items[i])
+	}
+
+	var httpRoutes []*gatewayv1.HTTPRoute
+	if gatewayAPI, err := gatewayapiclient.NewForConfig(k8sAPI.Config); err == nil {
+		if routeList, err := gatewayAPI.GatewayV1().HTTPRoutes(options.namespace).List(ctx, metav1.ListOptions{}); err == nil {
+			for i := range routeList.Items {
+				httpRoutes = append(httpRoutes, &routeList.Items[i])
+			}
+		}
+	}
+
+	return profiles.RenderIngressProfile(ingresses, httpRoutes, service, options.namespace, options.clusterDomain, w)
+}
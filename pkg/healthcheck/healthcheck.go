@@ -0,0 +1,178 @@
+// Package healthcheck runs a set of categorized diagnostic checks against a
+// Linkerd installation and reports the results in one of several output
+// formats, for use by `linkerd check` and similar commands.
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CategoryID identifies a group of related checks, e.g. "kubernetes-api".
+type CategoryID string
+
+// hintBaseURL is prepended to a check's hint anchor to build the
+// troubleshooting URL surfaced alongside a failure.
+const hintBaseURL = "https://linkerd.io/2/checks/#"
+
+// CheckFunc is a single check's logic. A non-nil error marks the check as
+// failed; its message is surfaced to the user alongside the check's hint.
+type CheckFunc func(context.Context) error
+
+// Options configures a HealthChecker. It's a struct (rather than bare
+// parameters) so new knobs can be added without breaking callers.
+type Options struct{}
+
+type check struct {
+	description string
+	hintAnchor  string
+	warning     bool
+	run         CheckFunc
+}
+
+type category struct {
+	id     CategoryID
+	checks []check
+}
+
+// HealthChecker runs a set of checks, grouped into categories in the order
+// they were added, and reports their results in one of several formats via
+// RunChecks.
+type HealthChecker struct {
+	opts       *Options
+	categories []*category
+	byID       map[CategoryID]*category
+}
+
+// NewHealthChecker constructs an empty HealthChecker. categories is
+// currently unused by the checker itself (categories are recorded in the
+// order they're first seen in Add) but is accepted so that callers can
+// declare up front which categories they intend to populate.
+func NewHealthChecker(categories []CategoryID, opts *Options) *HealthChecker {
+	return &HealthChecker{
+		opts: opts,
+		byID: map[CategoryID]*category{},
+	}
+}
+
+// Add registers a fatal check under the given category. hintAnchor, when
+// non-empty, links a failing check to its troubleshooting documentation at
+// hintBaseURL.
+func (hc *HealthChecker) Add(id CategoryID, description, hintAnchor string, run CheckFunc) {
+	hc.add(id, description, hintAnchor, false, run)
+}
+
+// AddWarning registers a non-fatal check: a failure is still reported, but
+// doesn't affect RunChecks' overall success return value, and is rendered as
+// a warning rather than an error where the output format distinguishes them
+// (currently SARIF's result level).
+func (hc *HealthChecker) AddWarning(id CategoryID, description, hintAnchor string, run CheckFunc) {
+	hc.add(id, description, hintAnchor, true, run)
+}
+
+func (hc *HealthChecker) add(id CategoryID, description, hintAnchor string, warning bool, run CheckFunc) {
+	c, ok := hc.byID[id]
+	if !ok {
+		c = &category{id: id}
+		hc.byID[id] = c
+		hc.categories = append(hc.categories, c)
+	}
+	c.checks = append(c.checks, check{
+		description: description,
+		hintAnchor:  hintAnchor,
+		warning:     warning,
+		run:         run,
+	})
+}
+
+// resultStatus classifies a single check's outcome.
+type resultStatus int
+
+const (
+	statusOK resultStatus = iota
+	statusFail
+	// statusErrored covers checks that panicked or timed out, rather than
+	// returning an ordinary error, so CI systems consuming junit/sarif
+	// output can tell "the check ran and failed" apart from "the check
+	// itself broke".
+	statusErrored
+)
+
+// checkResult is one check's outcome, flattened out of its category for
+// easy consumption by the output renderers.
+type checkResult struct {
+	category    CategoryID
+	description string
+	hintAnchor  string
+	warning     bool
+	status      resultStatus
+	err         error
+}
+
+func (r checkResult) hintURL() string {
+	if r.hintAnchor == "" {
+		return ""
+	}
+	return hintBaseURL + r.hintAnchor
+}
+
+// run executes every registered check in order and returns their flattened
+// results. A check that panics or exceeds ctx's deadline is recorded as
+// statusErrored rather than statusFail, and execution continues with the
+// remaining checks either way.
+func (hc *HealthChecker) run(ctx context.Context) []checkResult {
+	var results []checkResult
+	for _, cat := range hc.categories {
+		for _, c := range cat.checks {
+			err, errored := runCheck(ctx, c)
+			status := statusOK
+			switch {
+			case errored:
+				status = statusErrored
+			case err != nil:
+				status = statusFail
+			}
+			results = append(results, checkResult{
+				category:    cat.id,
+				description: c.description,
+				hintAnchor:  c.hintAnchor,
+				warning:     c.warning,
+				status:      status,
+				err:         err,
+			})
+		}
+	}
+	return results
+}
+
+// errPanicked wraps a recovered panic so runCheck can tell it apart from an
+// ordinary check failure.
+var errPanicked = errors.New("check panicked")
+
+// runCheck executes a single check, converting a panic into an error so one
+// broken check can't take down the rest of the run, and treats the check's
+// own context deadline the same way. The second return value reports
+// whether the check errored out (panicked or timed out) rather than simply
+// returning a failure.
+func runCheck(ctx context.Context, c check) (err error, errored bool) {
+	resultCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				resultCh <- fmt.Errorf("%w: %v", errPanicked, r)
+			}
+		}()
+		resultCh <- c.run(ctx)
+	}()
+
+	select {
+	case err := <-resultCh:
+		if err != nil && (errors.Is(err, errPanicked) || errors.Is(err, context.DeadlineExceeded)) {
+			return err, true
+		}
+		return err, false
+	case <-ctx.Done():
+		return ctx.Err(), true
+	}
+}
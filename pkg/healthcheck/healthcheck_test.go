@@ -0,0 +1,81 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunCheckRecoversFromPanic(t *testing.T) {
+	c := check{
+		description: "panics",
+		run: func(context.Context) error {
+			panic("boom")
+		},
+	}
+
+	err, errored := runCheck(context.Background(), c)
+	if !errored {
+		t.Fatal("expected a panicking check to be reported as errored")
+	}
+	if err == nil || !errors.Is(err, errPanicked) {
+		t.Fatalf("expected errPanicked, got %v", err)
+	}
+}
+
+func TestRunCheckReportsDeadlineExceededAsErrored(t *testing.T) {
+	c := check{
+		description: "never returns",
+		run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	err, errored := runCheck(ctx, c)
+	if !errored {
+		t.Fatal("expected a timed-out check to be reported as errored")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRunCheckReturnsOrdinaryFailureUnerrored(t *testing.T) {
+	c := check{
+		description: "fails",
+		run: func(context.Context) error {
+			return errors.New("nope")
+		},
+	}
+
+	err, errored := runCheck(context.Background(), c)
+	if errored {
+		t.Fatal("expected an ordinary failure not to be reported as errored")
+	}
+	if err == nil {
+		t.Fatal("expected a non-nil error")
+	}
+}
+
+func TestHealthCheckerAddGroupsByCategory(t *testing.T) {
+	hc := NewHealthChecker([]CategoryID{}, &Options{})
+	hc.Add("cat-a", "check1", "", func(context.Context) error { return nil })
+	hc.AddWarning("cat-a", "check2", "", func(context.Context) error { return errors.New("meh") })
+	hc.Add("cat-b", "check3", "", func(context.Context) error { return nil })
+
+	results := hc.run(context.Background())
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[1].category != "cat-a" || !results[1].warning {
+		t.Errorf("expected check2 to be a warning in cat-a, got %+v", results[1])
+	}
+	if results[2].category != "cat-b" {
+		t.Errorf("expected check3 in cat-b, got %+v", results[2])
+	}
+}
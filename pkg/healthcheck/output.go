@@ -0,0 +1,292 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Output format identifiers accepted by RunChecks.
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+	JUnitOutput = "junit"
+	SarifOutput = "sarif"
+)
+
+// RunChecks runs every check registered on hc and renders the results to
+// wout in the given format, returning whether every fatal (non-warning)
+// check passed. Unrecognized formats fall back to TableOutput. Errors
+// encountered while writing to wout are reported on werr.
+func RunChecks(wout, werr io.Writer, hc *HealthChecker, format string) bool {
+	results := hc.run(context.Background())
+
+	success := true
+	for _, r := range results {
+		if r.status != statusOK && !r.warning {
+			success = false
+		}
+	}
+
+	var err error
+	switch format {
+	case JSONOutput:
+		err = renderJSON(wout, results, success)
+	case JUnitOutput:
+		err = renderJUnit(wout, results)
+	case SarifOutput:
+		err = renderSarif(wout, results)
+	default:
+		err = renderTable(wout, results)
+	}
+	if err != nil {
+		fmt.Fprintf(werr, "Error rendering check results: %s\n", err)
+	}
+
+	return success
+}
+
+func statusSymbol(status resultStatus) string {
+	if status == statusOK {
+		return "√"
+	}
+	return "×"
+}
+
+func renderTable(w io.Writer, results []checkResult) error {
+	var currentCategory CategoryID
+	first := true
+	for _, r := range results {
+		if r.category != currentCategory {
+			if !first {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s\n", r.category)
+			currentCategory = r.category
+			first = false
+		}
+
+		fmt.Fprintf(w, "%s %s\n", statusSymbol(r.status), r.description)
+		if r.status == statusOK {
+			continue
+		}
+		fmt.Fprintf(w, "    %s\n", r.err)
+		if url := r.hintURL(); url != "" {
+			fmt.Fprintf(w, "    see %s for hints\n", url)
+		}
+	}
+	if !first {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+type jsonCheck struct {
+	Description string `json:"description"`
+	Hint        string `json:"hint,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Result      string `json:"result"`
+}
+
+type jsonCategory struct {
+	CategoryName string      `json:"categoryName"`
+	Checks       []jsonCheck `json:"checks"`
+}
+
+type jsonReport struct {
+	Success    bool           `json:"success"`
+	Categories []jsonCategory `json:"categories"`
+}
+
+func jsonResult(status resultStatus) string {
+	switch status {
+	case statusOK:
+		return "ok"
+	case statusErrored:
+		return "error"
+	default:
+		return "fail"
+	}
+}
+
+func renderJSON(w io.Writer, results []checkResult, success bool) error {
+	report := jsonReport{Success: success}
+
+	var current *jsonCategory
+	for _, r := range results {
+		if current == nil || current.CategoryName != string(r.category) {
+			report.Categories = append(report.Categories, jsonCategory{CategoryName: string(r.category)})
+			current = &report.Categories[len(report.Categories)-1]
+		}
+		entry := jsonCheck{
+			Description: r.description,
+			Hint:        r.hintURL(),
+			Result:      jsonResult(r.status),
+		}
+		if r.err != nil {
+			entry.Error = r.err.Error()
+		}
+		current.Checks = append(current.Checks, entry)
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// junitTestSuites, junitTestSuite and junitTestCase model just enough of the
+// JUnit XML schema for CI systems (and humans) to read per-category,
+// per-check results: one <testsuite> per CategoryID, one <testcase> per
+// check, with a <failure> or <error> child for non-passing checks.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name    string        `xml:"name,attr"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+	Error   *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func renderJUnit(w io.Writer, results []checkResult) error {
+	var suites []junitTestSuite
+	var current *junitTestSuite
+
+	for _, r := range results {
+		if current == nil || current.Name != string(r.category) {
+			suites = append(suites, junitTestSuite{Name: string(r.category)})
+			current = &suites[len(suites)-1]
+		}
+
+		tc := junitTestCase{Name: r.description}
+		switch r.status {
+		case statusFail:
+			current.Failures++
+			tc.Failure = &junitFailure{Message: r.err.Error(), Text: r.hintURL()}
+		case statusErrored:
+			current.Errors++
+			tc.Error = &junitFailure{Message: r.err.Error(), Text: r.hintURL()}
+		}
+		current.Tests++
+		current.Cases = append(current.Cases, tc)
+	}
+
+	doc := junitTestSuites{Suites: suites}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, xml.Header+string(out)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sarifLog, sarifRun, sarifResult model just enough of the SARIF 2.1.0
+// schema (https://docs.oasis-open.org/sarif/sarif/v2.1.0) for each failing
+// check to become one result, identified by its hint anchor, or by its
+// description when it has no hint anchor.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type sarifResult struct {
+	RuleID  string       `json:"ruleId"`
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+const sarifSchema = "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json"
+
+func sarifLevel(r checkResult) string {
+	switch {
+	case r.status != statusOK && r.warning:
+		return "warning"
+	case r.status != statusOK:
+		return "error"
+	default:
+		return "none"
+	}
+}
+
+func sarifRuleID(r checkResult) string {
+	if r.hintAnchor != "" {
+		return r.hintAnchor
+	}
+	return r.description
+}
+
+func renderSarif(w io.Writer, results []checkResult) error {
+	var sarifResults []sarifResult
+	for _, r := range results {
+		if r.status == statusOK {
+			continue
+		}
+		sarifResults = append(sarifResults, sarifResult{
+			RuleID: sarifRuleID(r),
+			Level:  sarifLevel(r),
+			Message: sarifMessage{
+				Text: r.err.Error(),
+			},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{Name: "linkerd-check", Version: "dev"},
+				},
+				Results: sarifResults,
+			},
+		},
+	}
+
+	out, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
@@ -0,0 +1,212 @@
+package profiles
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	sp "github.com/linkerd/linkerd2/controller/gen/apis/serviceprofile/v1alpha2"
+	log "github.com/sirupsen/logrus"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// placeholderRegex is the regex fragment substituted for a named
+// `{param}`-style placeholder in an ImplementationSpecific Ingress path.
+// Unlike the tap path templater (path_template.go), which can infer that a
+// segment is an integer, UUID, etc. from observed traffic, an Ingress only
+// tells us a segment varies, not what it looks like, so we fall back to
+// matching any non-"/" run of characters. Both generators anchor the
+// resulting PathRegex the same way, so profiles produced from tap data and
+// from Ingress/HTTPRoute resources are compatible with each other.
+const placeholderRegex = `[^/]+`
+
+var ingressPlaceholderSegment = regexp.MustCompile(`^\{.+\}$`)
+
+// RenderIngressProfile builds a ServiceProfile for the named service from
+// the Ingress and/or HTTPRoute resources that route traffic to it, writing
+// the result as YAML to w. Multiple Ingress (and HTTPRoute) objects that
+// target the same service are merged into a single deduplicated profile.
+//
+// The ServiceProfile RouteSpec schema only supports matching on path and
+// method, with no way to express a header or query-parameter condition. An
+// HTTPRoute rule that matches on those is still represented by its
+// path/method alone, minus that extra precision; routeSpecsFromHTTPRoute
+// logs a warning each time this happens, rather than silently dropping the
+// condition, so the gap is visible instead of assumed away.
+func RenderIngressProfile(ingresses []*networkingv1.Ingress, httpRoutes []*gatewayv1.HTTPRoute, service, namespace, clusterDomain string, w io.Writer) error {
+	profile := sp.ServiceProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%s.svc.%s", service, namespace, clusterDomain),
+			Namespace: namespace,
+		},
+		TypeMeta: serviceProfileMeta,
+	}
+
+	routesMap := make(map[string]*sp.RouteSpec)
+
+	for _, ingress := range ingresses {
+		for _, route := range routeSpecsFromIngress(ingress, service) {
+			routesMap[routeKey(route)] = route
+		}
+	}
+	for _, httpRoute := range httpRoutes {
+		for _, route := range routeSpecsFromHTTPRoute(httpRoute, service) {
+			routesMap[routeKey(route)] = route
+		}
+	}
+
+	keys := make([]string, 0, len(routesMap))
+	for k := range routesMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	routes := make([]*sp.RouteSpec, 0, len(keys))
+	for _, k := range keys {
+		routes = append(routes, routesMap[k])
+	}
+	profile.Spec.Routes = routes
+
+	output, err := yaml.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("Error writing Service Profile: %s", err)
+	}
+	w.Write(output)
+	return nil
+}
+
+// routeKey dedups routes the same way consolidatePaths's dedupKey does in
+// path_template.go: by (method, path), not path alone, so two matches on the
+// same path that differ only by method (e.g. a GET and a POST to the same
+// URL) don't collapse into a single route.
+func routeKey(route *sp.RouteSpec) string {
+	return route.Condition.Method + " " + route.Name
+}
+
+// routeSpecsFromIngress builds one RouteSpec per HTTP path rule that targets
+// service, across every rule in every host of the Ingress. When a rule has
+// no paths at all (a host-only rule), a catch-all route is emitted instead,
+// since that's the only way to describe "everything served on this host".
+func routeSpecsFromIngress(ingress *networkingv1.Ingress, service string) []*sp.RouteSpec {
+	var routes []*sp.RouteSpec
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil || len(rule.HTTP.Paths) == 0 {
+			routes = append(routes, mkRouteSpec("/", "^/.*$", "", nil))
+			continue
+		}
+
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service == nil || p.Backend.Service.Name != service {
+				continue
+			}
+			pathType := networkingv1.PathTypeImplementationSpecific
+			if p.PathType != nil {
+				pathType = *p.PathType
+			}
+			routes = append(routes, mkRouteSpec(p.Path, ingressPathRegex(p.Path, pathType), "", nil))
+		}
+	}
+
+	return routes
+}
+
+// ingressPathRegex translates a single Ingress path into the anchored regex
+// grammar used across the profiles package. Prefix and Exact matches are a
+// direct translation of the Kubernetes semantics; ImplementationSpecific
+// paths are assumed to use the common `{var}` placeholder convention (as
+// nginx-ingress and others do) and are translated segment by segment.
+func ingressPathRegex(path string, pathType networkingv1.PathType) string {
+	switch pathType {
+	case networkingv1.PathTypeExact:
+		return "^" + regexp.QuoteMeta(path) + "$"
+	case networkingv1.PathTypePrefix:
+		trimmed := strings.TrimSuffix(path, "/")
+		return "^" + regexp.QuoteMeta(trimmed) + "(/.*)?$"
+	default:
+		return implementationSpecificPathRegex(path)
+	}
+}
+
+func implementationSpecificPathRegex(path string) string {
+	segments := pathSegments(path)
+	parts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if ingressPlaceholderSegment.MatchString(seg) {
+			parts = append(parts, placeholderRegex)
+		} else {
+			parts = append(parts, regexp.QuoteMeta(seg))
+		}
+	}
+	return "^/" + strings.Join(parts, "/") + "$"
+}
+
+// routeSpecsFromHTTPRoute builds one RouteSpec per rule/match pair in the
+// HTTPRoute whose backendRefs target service.
+func routeSpecsFromHTTPRoute(httpRoute *gatewayv1.HTTPRoute, service string) []*sp.RouteSpec {
+	var routes []*sp.RouteSpec
+
+	for _, rule := range httpRoute.Spec.Rules {
+		targetsService := false
+		for _, ref := range rule.BackendRefs {
+			if string(ref.Name) == service {
+				targetsService = true
+				break
+			}
+		}
+		if !targetsService {
+			continue
+		}
+
+		if len(rule.Matches) == 0 {
+			routes = append(routes, mkRouteSpec("/", "^/.*$", "", nil))
+			continue
+		}
+
+		for _, match := range rule.Matches {
+			method := ""
+			if match.Method != nil {
+				method = string(*match.Method)
+			}
+
+			if len(match.Headers) > 0 || len(match.QueryParams) > 0 {
+				log.Warnf("HTTPRoute %s/%s matches on headers/query params, which ServiceProfile RouteSpec cannot represent; the generated route will only match on path and method", httpRoute.Namespace, httpRoute.Name)
+			}
+
+			path, regex := httpRouteMatchPathRegex(match)
+			routes = append(routes, mkRouteSpec(path, regex, method, nil))
+		}
+	}
+
+	return routes
+}
+
+func httpRouteMatchPathRegex(match gatewayv1.HTTPRouteMatch) (path string, pathRegex string) {
+	if match.Path == nil || match.Path.Value == nil {
+		return "/", "^/.*$"
+	}
+	path = *match.Path.Value
+
+	matchType := gatewayv1.PathMatchPathPrefix
+	if match.Path.Type != nil {
+		matchType = *match.Path.Type
+	}
+
+	switch matchType {
+	case gatewayv1.PathMatchExact:
+		return path, "^" + regexp.QuoteMeta(path) + "$"
+	case gatewayv1.PathMatchRegularExpression:
+		if strings.HasPrefix(path, "^") {
+			return path, path
+		}
+		return path, "^" + path + "$"
+	default: // PathMatchPathPrefix
+		trimmed := strings.TrimSuffix(path, "/")
+		return path, "^" + regexp.QuoteMeta(trimmed) + "(/.*)?$"
+	}
+}
@@ -0,0 +1,275 @@
+package profiles
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	sp "github.com/linkerd/linkerd2/controller/gen/apis/serviceprofile/v1alpha2"
+	log "github.com/sirupsen/logrus"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func pathType(t networkingv1.PathType) *networkingv1.PathType { return &t }
+
+func stringPtr(s string) *string { return &s }
+
+func ingressRule(host string, paths ...networkingv1.HTTPIngressPath) networkingv1.IngressRule {
+	return networkingv1.IngressRule{
+		Host: host,
+		IngressRuleValue: networkingv1.IngressRuleValue{
+			HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+		},
+	}
+}
+
+func ingressPath(path string, pt *networkingv1.PathType, service string) networkingv1.HTTPIngressPath {
+	return networkingv1.HTTPIngressPath{
+		Path:     path,
+		PathType: pt,
+		Backend: networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{Name: service},
+		},
+	}
+}
+
+func TestRouteSpecsFromIngress(t *testing.T) {
+	t.Run("exact and prefix paths map directly to PathRegex", func(t *testing.T) {
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "web"},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					ingressRule("example.com",
+						ingressPath("/healthz", pathType(networkingv1.PathTypeExact), "web"),
+						ingressPath("/api", pathType(networkingv1.PathTypePrefix), "web"),
+					),
+				},
+			},
+		}
+
+		routes := routeSpecsFromIngress(ingress, "web")
+		if len(routes) != 2 {
+			t.Fatalf("expected 2 routes, got %d", len(routes))
+		}
+		if got, want := routes[0].Condition.PathRegex, "^/healthz$"; got != want {
+			t.Errorf("exact path regex = %s, want %s", got, want)
+		}
+		if got, want := routes[1].Condition.PathRegex, "^/api(/.*)?$"; got != want {
+			t.Errorf("prefix path regex = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("paths targeting a different service are skipped", func(t *testing.T) {
+		ingress := &networkingv1.Ingress{
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					ingressRule("example.com",
+						ingressPath("/web", pathType(networkingv1.PathTypePrefix), "web"),
+						ingressPath("/other", pathType(networkingv1.PathTypePrefix), "other-svc"),
+					),
+				},
+			},
+		}
+
+		routes := routeSpecsFromIngress(ingress, "web")
+		if len(routes) != 1 || routes[0].Name != "/web" {
+			t.Fatalf("expected only the /web route, got %v", routeNames(routes))
+		}
+	})
+
+	t.Run("host-only rule falls back to a catch-all route", func(t *testing.T) {
+		ingress := &networkingv1.Ingress{
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{Host: "example.com"},
+				},
+			},
+		}
+
+		routes := routeSpecsFromIngress(ingress, "web")
+		if len(routes) != 1 || routes[0].Name != "/" {
+			t.Fatalf("expected a single catch-all route, got %v", routeNames(routes))
+		}
+	})
+
+	t.Run("ImplementationSpecific paths translate {var} placeholders", func(t *testing.T) {
+		ingress := &networkingv1.Ingress{
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					ingressRule("example.com",
+						ingressPath("/users/{id}/orders/{orderId}", pathType(networkingv1.PathTypeImplementationSpecific), "web"),
+					),
+				},
+			},
+		}
+
+		routes := routeSpecsFromIngress(ingress, "web")
+		if len(routes) != 1 {
+			t.Fatalf("expected 1 route, got %d", len(routes))
+		}
+		want := "^/users/" + placeholderRegex + "/orders/" + placeholderRegex + "$"
+		if got := implementationSpecificPathRegex("/users/{id}/orders/{orderId}"); got != want {
+			t.Errorf("implementationSpecificPathRegex() = %s, want %s", got, want)
+		}
+	})
+}
+
+func TestRenderIngressProfileMergesMultipleIngresses(t *testing.T) {
+	first := &networkingv1.Ingress{
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				ingressRule("a.example.com", ingressPath("/a", pathType(networkingv1.PathTypePrefix), "web")),
+			},
+		},
+	}
+	second := &networkingv1.Ingress{
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				ingressRule("b.example.com", ingressPath("/b", pathType(networkingv1.PathTypePrefix), "web")),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderIngressProfile([]*networkingv1.Ingress{first, second}, nil, "web", "ns", "cluster.local", &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var profile sp.ServiceProfile
+	if err := yaml.Unmarshal(buf.Bytes(), &profile); err != nil {
+		t.Fatalf("failed to parse rendered YAML: %v\n%s", err, buf.String())
+	}
+
+	names := routeNames(profile.Spec.Routes)
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "/a" || names[1] != "/b" {
+		t.Fatalf("expected routes /a and /b, got %v", names)
+	}
+}
+
+func httpRouteMatch(method gatewayv1.HTTPMethod, path string) gatewayv1.HTTPRouteMatch {
+	m := method
+	pathValue := path
+	return gatewayv1.HTTPRouteMatch{
+		Method: &m,
+		Path:   &gatewayv1.HTTPPathMatch{Value: &pathValue},
+	}
+}
+
+func backendRef(service string) gatewayv1.HTTPBackendRef {
+	return gatewayv1.HTTPBackendRef{
+		BackendRef: gatewayv1.BackendRef{
+			BackendObjectReference: gatewayv1.BackendObjectReference{Name: gatewayv1.ObjectName(service)},
+		},
+	}
+}
+
+func TestRenderIngressProfileMergesIngressAndHTTPRouteInputs(t *testing.T) {
+	ingress := &networkingv1.Ingress{
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				ingressRule("example.com", ingressPath("/web", pathType(networkingv1.PathTypePrefix), "web")),
+			},
+		},
+	}
+	httpRoute := &gatewayv1.HTTPRoute{
+		Spec: gatewayv1.HTTPRouteSpec{
+			Rules: []gatewayv1.HTTPRouteRule{
+				{
+					Matches:     []gatewayv1.HTTPRouteMatch{httpRouteMatch(gatewayv1.HTTPMethodGet, "/api")},
+					BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web")},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := RenderIngressProfile(
+		[]*networkingv1.Ingress{ingress},
+		[]*gatewayv1.HTTPRoute{httpRoute},
+		"web", "ns", "cluster.local", &buf,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var profile sp.ServiceProfile
+	if err := yaml.Unmarshal(buf.Bytes(), &profile); err != nil {
+		t.Fatalf("failed to parse rendered YAML: %v\n%s", err, buf.String())
+	}
+
+	if profile.ObjectMeta.Name != "web.ns.svc.cluster.local" || profile.ObjectMeta.Namespace != "ns" {
+		t.Errorf("unexpected profile metadata: %+v", profile.ObjectMeta)
+	}
+
+	names := routeNames(profile.Spec.Routes)
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "/api" || names[1] != "/web" {
+		t.Fatalf("expected routes /api (from the HTTPRoute) and /web (from the Ingress) in the rendered profile, got %v", names)
+	}
+}
+
+func TestRouteSpecsFromHTTPRouteWarnsButStillMatchesOnHeaderOrQueryConditions(t *testing.T) {
+	headerValue := "x-my-header"
+	httpRoute := &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-route", Namespace: "ns"},
+		Spec: gatewayv1.HTTPRouteSpec{
+			Rules: []gatewayv1.HTTPRouteRule{
+				{
+					Matches: []gatewayv1.HTTPRouteMatch{
+						{
+							Path:    &gatewayv1.HTTPPathMatch{Value: stringPtr("/web")},
+							Headers: []gatewayv1.HTTPHeaderMatch{{Name: gatewayv1.HTTPHeaderName(headerValue), Value: "1"}},
+						},
+					},
+					BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web")},
+				},
+			},
+		},
+	}
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	routes := routeSpecsFromHTTPRoute(httpRoute, "web")
+	if len(routes) != 1 || routes[0].Name != "/web" {
+		t.Fatalf("expected a single /web route (header condition unrepresentable, path/method still used), got %v", routeNames(routes))
+	}
+	if !strings.Contains(logBuf.String(), "headers/query params") {
+		t.Errorf("expected a warning about the unrepresentable header condition, got log output %q", logBuf.String())
+	}
+}
+
+func TestRenderIngressProfileKeepsRoutesWithSameServicePathDistinctMethods(t *testing.T) {
+	httpRoute := &gatewayv1.HTTPRoute{
+		Spec: gatewayv1.HTTPRouteSpec{
+			Rules: []gatewayv1.HTTPRouteRule{
+				{
+					Matches: []gatewayv1.HTTPRouteMatch{
+						httpRouteMatch(gatewayv1.HTTPMethodGet, "/widgets"),
+						httpRouteMatch(gatewayv1.HTTPMethodPost, "/widgets"),
+					},
+					BackendRefs: []gatewayv1.HTTPBackendRef{backendRef("web")},
+				},
+			},
+		},
+	}
+
+	routes := routeSpecsFromHTTPRoute(httpRoute, "web")
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d: %v", len(routes), routeNames(routes))
+	}
+
+	keys := make(map[string]bool)
+	for _, route := range routes {
+		keys[routeKey(route)] = true
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected GET and POST /widgets to dedup to distinct keys, got %v", keys)
+	}
+}
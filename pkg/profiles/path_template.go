@@ -0,0 +1,302 @@
+package profiles
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	sp "github.com/linkerd/linkerd2/controller/gen/apis/serviceprofile/v1alpha2"
+)
+
+// segmentClass identifies the structural shape of a single path segment so
+// that paths which only differ in their variable segments (numeric ids,
+// UUIDs, etc.) can be clustered together and templated.
+type segmentClass int
+
+const (
+	classLiteral segmentClass = iota
+	classInt
+	classUUID
+	classHex
+	classOpaque
+)
+
+var (
+	uuidSegmentRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	intSegmentRegex  = regexp.MustCompile(`^[0-9]+$`)
+	hexSegmentRegex  = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+	// opaqueSegmentRegex matches long, mixed alphanumeric tokens (e.g. hashes,
+	// opaque API keys) that aren't plain words but also aren't one of the
+	// more specific id shapes above.
+	opaqueSegmentRegex = regexp.MustCompile(`^[0-9a-zA-Z_-]{12,}$`)
+)
+
+// hexSegmentLengths holds the lengths of hex-encoded ids actually seen in
+// practice (CRC32, a short SHA, MD5, SHA-1 and SHA-256). A segment made up
+// entirely of hex characters is only classified as classHex if it matches
+// one of these; a string can consist entirely of hex characters and still be an
+// arbitrary opaque token (e.g. "a1b2c3d4e5f6a7b8c9d0"), so length is what
+// distinguishes a real hex id from that coincidence.
+var hexSegmentLengths = map[int]bool{8: true, 16: true, 32: true, 40: true, 64: true}
+
+// classifySegment inspects a single `/`-delimited path segment in isolation
+// and returns the class of id it looks like, if any. Classification order
+// matters: a UUID is also valid hex-with-dashes-stripped, and an all-digit
+// hex string is also a valid integer, so the more specific classes are
+// checked first.
+func classifySegment(segment string) segmentClass {
+	switch {
+	case uuidSegmentRegex.MatchString(segment):
+		return classUUID
+	case intSegmentRegex.MatchString(segment):
+		return classInt
+	case hexSegmentRegex.MatchString(segment) && hexSegmentLengths[len(segment)]:
+		return classHex
+	case opaqueSegmentRegex.MatchString(segment) && hasDigit(segment):
+		return classOpaque
+	default:
+		return classLiteral
+	}
+}
+
+func hasDigit(s string) bool {
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			return true
+		}
+	}
+	return false
+}
+
+// pathSegments splits a request path on "/", dropping the empty leading
+// (and, if present, trailing) element produced by the leading slash.
+func pathSegments(path string) []string {
+	parts := strings.Split(path, "/")
+	if len(parts) > 0 && parts[0] == "" {
+		parts = parts[1:]
+	}
+	if len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return parts
+}
+
+// clusterKey identifies a group of paths that should be consolidated into a
+// single templated route: same method, same segment count, and the same
+// class at every position, with literal segments additionally required to
+// match by value (otherwise unrelated literal endpoints of the same shape,
+// e.g. /users/alice and /orders/new, would collapse into one route).
+func clusterKey(method string, segments []string) string {
+	parts := make([]string, 0, len(segments)+1)
+	parts = append(parts, method)
+	for _, seg := range segments {
+		switch classifySegment(seg) {
+		case classInt:
+			parts = append(parts, "<int>")
+		case classUUID:
+			parts = append(parts, "<uuid>")
+		case classHex:
+			parts = append(parts, "<hex>")
+		case classOpaque:
+			parts = append(parts, "<opaque>")
+		default:
+			parts = append(parts, "="+seg)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// pathCluster accumulates every observed path that shares a clusterKey, so
+// that once all tap data has been collected a single templated RouteSpec can
+// be built from the group.
+type pathCluster struct {
+	method   string
+	segments []string // segments of the first path seen, used as a shape reference
+	paths    map[string]bool
+}
+
+// templateName derives a parameter name for the variable segment at
+// position i, preferring the adjacent literal segment (e.g. "users" ->
+// "id", "orders" -> "order_id") and falling back to a positional name when
+// no literal prefix is available.
+func templateName(segments []string, i int, varIndex int) string {
+	if i > 0 && classifySegment(segments[i-1]) == classLiteral {
+		if varIndex == 0 {
+			return "id"
+		}
+		return singularize(segments[i-1]) + "_id"
+	}
+	return fmt.Sprintf("p%d", varIndex+1)
+}
+
+// singularize applies a best-effort, English-only pluralization strip. It is
+// only used for cosmetic parameter naming, so a wrong guess (e.g. "status"
+// -> "statu") merely produces an odd-looking but still valid parameter name.
+func singularize(word string) string {
+	if strings.HasSuffix(word, "ies") && len(word) > 3 {
+		return word[:len(word)-3] + "y"
+	}
+	if strings.HasSuffix(word, "s") && len(word) > 1 {
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// classRegex returns the regex fragment used to match a templated segment of
+// the given class. Each fragment mirrors that class's own predicate in
+// classifySegment, but not the order in which classifySegment tries the
+// classes: the hex and opaque fragments also match all-digit segments of
+// their accepted lengths (which classifySegment puts in classInt), and the
+// opaque fragment also matches UUIDs and hex ids of 12 or more characters
+// that contain a digit. A generated PathRegex can therefore also match
+// segments that were clustered into another route.
+func classRegex(class segmentClass) string {
+	switch class {
+	case classInt:
+		return `[0-9]+`
+	case classUUID:
+		return `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`
+	case classHex:
+		return hexClassRegexFragment
+	case classOpaque:
+		return opaqueClassRegexFragment
+	default:
+		return ""
+	}
+}
+
+// hexClassRegexFragment mirrors classifySegment's classHex predicate
+// (hexSegmentRegex plus hexSegmentLengths) as an alternation over the exact
+// accepted lengths, rather than the open-ended `{8,}` used previously, which
+// matched hex strings of any length the classifier itself would never
+// recognize as classHex.
+var hexClassRegexFragment = buildHexClassRegexFragment()
+
+func buildHexClassRegexFragment() string {
+	lengths := make([]int, 0, len(hexSegmentLengths))
+	for n := range hexSegmentLengths {
+		lengths = append(lengths, n)
+	}
+	sort.Ints(lengths)
+
+	branches := make([]string, len(lengths))
+	for i, n := range lengths {
+		branches[i] = fmt.Sprintf(`[0-9a-fA-F]{%d}`, n)
+	}
+	return "(?:" + strings.Join(branches, "|") + ")"
+}
+
+// opaqueClassRegexFragment mirrors classifySegment's classOpaque predicate:
+// opaqueSegmentRegex (length >= minOpaqueLen over its charset) AND hasDigit.
+// RE2 (the engine behind Go's regexp package) has no lookahead, so "contains
+// a digit" can't be bolted onto `{12,}` directly; instead this case-splits
+// on the position of the first digit. If that digit appears within the
+// first minOpaqueLen-1 characters, the non-digit prefix before it is fixed
+// at that exact length and the suffix is required to make up the rest of
+// minOpaqueLen; otherwise the prefix alone is already long enough and the
+// suffix is unconstrained.
+var opaqueClassRegexFragment = buildOpaqueClassRegexFragment()
+
+const minOpaqueLen = 12
+
+func buildOpaqueClassRegexFragment() string {
+	branches := make([]string, 0, minOpaqueLen)
+	for prefixLen := 0; prefixLen < minOpaqueLen-1; prefixLen++ {
+		suffixMin := minOpaqueLen - 1 - prefixLen
+		branches = append(branches, fmt.Sprintf(`[a-zA-Z_-]{%d}[0-9][0-9a-zA-Z_-]{%d,}`, prefixLen, suffixMin))
+	}
+	branches = append(branches, fmt.Sprintf(`[a-zA-Z_-]{%d,}[0-9][0-9a-zA-Z_-]*`, minOpaqueLen-1))
+	return "(?:" + strings.Join(branches, "|") + ")"
+}
+
+// templatedPath builds the display path (with named {param} placeholders)
+// and the corresponding anchored path regex for a cluster, using the first
+// path observed in the cluster as the shape reference.
+func templatedPath(method string, segments []string) (path string, pathRegex string) {
+	var pathParts []string
+	var regexParts []string
+	varIndex := 0
+
+	for i, seg := range segments {
+		class := classifySegment(seg)
+		if class == classLiteral {
+			pathParts = append(pathParts, seg)
+			regexParts = append(regexParts, regexp.QuoteMeta(seg))
+			continue
+		}
+		name := templateName(segments, i, varIndex)
+		varIndex++
+		pathParts = append(pathParts, "{"+name+"}")
+		regexParts = append(regexParts, classRegex(class))
+	}
+
+	path = "/" + strings.Join(pathParts, "/")
+	pathRegex = "^/" + strings.Join(regexParts, "/") + "$"
+	return path, pathRegex
+}
+
+// consolidatePaths groups the given (method, path) observations by
+// structural similarity and returns one RouteSpec per cluster, with variable
+// segments (ids, UUIDs, hex and opaque tokens) collapsed into named
+// placeholders. Clusters that reduce to the root path ("/") are dropped, and
+// routes are deduplicated by (method, templated path).
+//
+// statsByPath holds the per-raw-path response stats gathered while tapping
+// (see response_stats.go); when a cluster groups several raw paths together,
+// their stats are merged before the route's response classes, timeout and
+// retryability are derived from them via opts.
+func consolidatePaths(observations map[string][]string, statsByPath map[string]*routeStats, opts TapProfileOptions) ([]*sp.RouteSpec, []*routeStats) {
+	clusters := make(map[string]*pathCluster)
+	var order []string
+
+	for method, paths := range observations {
+		for _, path := range paths {
+			if path == "/" {
+				continue
+			}
+			segments := pathSegments(path)
+			key := clusterKey(method, segments)
+			c, ok := clusters[key]
+			if !ok {
+				c = &pathCluster{method: method, segments: segments, paths: map[string]bool{}}
+				clusters[key] = c
+				order = append(order, key)
+			}
+			c.paths[path] = true
+		}
+	}
+
+	// Sort by cluster key so output is deterministic regardless of map
+	// iteration order over observations.
+	sort.Strings(order)
+
+	seen := make(map[string]bool)
+	routes := make([]*sp.RouteSpec, 0, len(clusters))
+	allStats := make([]*routeStats, 0, len(clusters))
+	for _, key := range order {
+		c := clusters[key]
+		path, regex := templatedPath(c.method, c.segments)
+		if path == "/" {
+			continue
+		}
+		dedupKey := c.method + " " + path
+		if seen[dedupKey] {
+			continue
+		}
+		seen[dedupKey] = true
+
+		route := mkRouteSpec(path, regex, c.method, nil)
+
+		stats := newRouteStats()
+		for rawPath := range c.paths {
+			stats.merge(statsByPath[rawPath])
+		}
+		decorateRoute(route, stats, opts)
+
+		routes = append(routes, route)
+		allStats = append(allStats, stats)
+	}
+
+	return routes, allStats
+}
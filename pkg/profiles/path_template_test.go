@@ -0,0 +1,131 @@
+package profiles
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+
+	sp "github.com/linkerd/linkerd2/controller/gen/apis/serviceprofile/v1alpha2"
+)
+
+func TestClassifySegment(t *testing.T) {
+	testCases := []struct {
+		segment string
+		class   segmentClass
+	}{
+		{"users", classLiteral},
+		{"123", classInt},
+		{"987654321", classInt},
+		{"550e8400-e29b-41d4-a716-446655440000", classUUID},
+		{"deadbeefcafebabe", classHex},
+		{"a1b2c3d4e5f6a7b8c9d0", classOpaque},
+		{"v1", classLiteral},
+	}
+
+	for _, tc := range testCases {
+		if class := classifySegment(tc.segment); class != tc.class {
+			t.Errorf("classifySegment(%q) = %v, want %v", tc.segment, class, tc.class)
+		}
+	}
+}
+
+// TestClassRegexMatchesOnlyWhatTheClassifierAccepts guards against classRegex
+// drifting out of sync with classifySegment's per-class predicates on the
+// cases below (e.g. a hex-length regex that also matches opaque tokens, or
+// an opaque regex that also matches plain words). Segments that
+// classifySegment assigns to an earlier class, such as all-digit ones, are
+// not covered.
+func TestClassRegexMatchesOnlyWhatTheClassifierAccepts(t *testing.T) {
+	testCases := []struct {
+		segment string
+		class   segmentClass
+		matches bool
+	}{
+		{"deadbeefcafebabe", classHex, true},
+		{"a1b2c3d4e5f6a7b8c9d0", classHex, false},
+		{"a1b2c3d4e5f6a7b8c9d0", classOpaque, true},
+		{"abcdefghijkl", classOpaque, false}, // 12 letters, no digit
+		{"abcdefghijk1", classOpaque, true},  // digit at the very end
+		{"1abcdefghijk", classOpaque, true},  // digit at the very start
+	}
+
+	for _, tc := range testCases {
+		re := regexp.MustCompile("^" + classRegex(tc.class) + "$")
+		if got := re.MatchString(tc.segment); got != tc.matches {
+			t.Errorf("classRegex(%v) matching %q = %v, want %v", tc.class, tc.segment, got, tc.matches)
+		}
+	}
+}
+
+func TestConsolidatePaths(t *testing.T) {
+	t.Run("collapses id collisions into a single route", func(t *testing.T) {
+		observations := map[string][]string{
+			"GET": {
+				"/users/1",
+				"/users/2",
+				"/users/42",
+			},
+		}
+
+		routes, _ := consolidatePaths(observations, nil, TapProfileOptions{})
+		if len(routes) != 1 {
+			t.Fatalf("expected 1 route, got %d: %v", len(routes), routeNames(routes))
+		}
+		if routes[0].Name != "/users/{id}" {
+			t.Errorf("expected path /users/{id}, got %s", routes[0].Name)
+		}
+	})
+
+	t.Run("collapses UUIDs and keeps literal prefixes distinct", func(t *testing.T) {
+		observations := map[string][]string{
+			"GET": {
+				"/widgets/550e8400-e29b-41d4-a716-446655440000",
+				"/widgets/6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+				"/gadgets/new",
+			},
+		}
+
+		routes, _ := consolidatePaths(observations, nil, TapProfileOptions{})
+		names := routeNames(routes)
+		sort.Strings(names)
+		if len(names) != 2 || names[0] != "/gadgets/new" || names[1] != "/widgets/{id}" {
+			t.Fatalf("unexpected routes: %v", names)
+		}
+	})
+
+	t.Run("mixed literal and variable prefixes name params from neighbours", func(t *testing.T) {
+		observations := map[string][]string{
+			"GET": {
+				"/users/1/orders/100",
+				"/users/2/orders/200",
+				"/users/3/orders/300",
+			},
+		}
+
+		routes, _ := consolidatePaths(observations, nil, TapProfileOptions{})
+		if len(routes) != 1 {
+			t.Fatalf("expected 1 route, got %d: %v", len(routes), routeNames(routes))
+		}
+		if want := "/users/{id}/orders/{order_id}"; routes[0].Name != want {
+			t.Errorf("expected path %s, got %s", want, routes[0].Name)
+		}
+	})
+
+	t.Run("drops the root path", func(t *testing.T) {
+		observations := map[string][]string{
+			"GET": {"/"},
+		}
+		routes, _ := consolidatePaths(observations, nil, TapProfileOptions{})
+		if len(routes) != 0 {
+			t.Fatalf("expected no routes, got %v", routeNames(routes))
+		}
+	})
+}
+
+func routeNames(routes []*sp.RouteSpec) []string {
+	names := make([]string, 0, len(routes))
+	for _, r := range routes {
+		names = append(names, r.Name)
+	}
+	return names
+}
@@ -0,0 +1,226 @@
+package profiles
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	sp "github.com/linkerd/linkerd2/controller/gen/apis/serviceprofile/v1alpha2"
+)
+
+const (
+	// maxLatencySamples bounds how many latency samples a routeStats keeps
+	// verbatim; once full, new samples replace a uniformly-chosen existing
+	// one (reservoir sampling) so the retained set stays representative no
+	// matter how long the tap runs.
+	maxLatencySamples = 1000
+
+	// defaultFailurePercentile is the latency percentile used to derive a
+	// route's suggested timeout when --failure-percentile isn't set.
+	defaultFailurePercentile = 0.99
+
+	// nonTrivialFailureRatio is the minimum observed failure ratio (failed
+	// responses / total responses on a route) before that route is marked
+	// retryable and a profile-wide RetryBudget is suggested.
+	nonTrivialFailureRatio = 0.01
+
+	// fourXXFailureRatio is the default minimum share of 4xx responses, of
+	// all responses observed on a route, before those statuses are folded
+	// into the route's ResponseClasses as failures too. Overridden by
+	// --fourxx-failure-threshold via TapProfileOptions.FourXXFailureRatio.
+	fourXXFailureRatio = 0.1
+)
+
+// routeStats accumulates the HTTP status distribution and latency samples
+// observed for a route (or, prior to path consolidation, a single raw path)
+// over the course of a tap session. Its methods tolerate a partially-filled
+// routeStats, so a tap stream that's interrupted mid-session still yields a
+// best-effort profile; responseClasses also accepts a nil receiver. The zero
+// value is not ready for recording: recordStatus and merge need the map that
+// newRouteStats allocates.
+type routeStats struct {
+	statusCounts map[uint32]int
+	latencies    []time.Duration
+	totalSeen    int
+}
+
+func newRouteStats() *routeStats {
+	return &routeStats{statusCounts: map[uint32]int{}}
+}
+
+func (s *routeStats) recordStatus(status uint32) {
+	s.statusCounts[status]++
+}
+
+func (s *routeStats) recordLatency(d time.Duration) {
+	s.totalSeen++
+	if len(s.latencies) < maxLatencySamples {
+		s.latencies = append(s.latencies, d)
+		return
+	}
+	if i := rand.Intn(s.totalSeen); i < maxLatencySamples {
+		s.latencies[i] = d
+	}
+}
+
+// merge folds another routeStats' observations into this one, used when
+// several raw paths are consolidated into a single templated route.
+func (s *routeStats) merge(other *routeStats) {
+	if other == nil {
+		return
+	}
+	for status, count := range other.statusCounts {
+		s.statusCounts[status] += count
+	}
+	for _, d := range other.latencies {
+		s.recordLatency(d)
+	}
+}
+
+func (s *routeStats) total() int {
+	total := 0
+	for _, count := range s.statusCounts {
+		total += count
+	}
+	return total
+}
+
+// failureRatio returns the fraction of responses that count as failures:
+// always 5xx, plus 4xx when it makes up more than fourXXThreshold of all
+// responses on the route.
+func (s *routeStats) failureRatio(fourXXThreshold float64) float64 {
+	total := s.total()
+	if total == 0 {
+		return 0
+	}
+	var serverErrors, clientErrors int
+	for status, count := range s.statusCounts {
+		switch {
+		case status >= 500:
+			serverErrors += count
+		case status >= 400:
+			clientErrors += count
+		}
+	}
+	failures := serverErrors
+	if float64(clientErrors)/float64(total) > fourXXThreshold {
+		failures += clientErrors
+	}
+	return float64(failures) / float64(total)
+}
+
+// percentileLatency returns the p-th percentile (0 < p <= 1) latency from
+// the retained sample reservoir, or 0 if no samples were recorded.
+func (s *routeStats) percentileLatency(p float64) time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(s.latencies))
+	copy(sorted, s.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// Nearest-rank method: the p-th percentile of n samples is the
+	// ceil(p*n)-th smallest value.
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// responseClasses builds the route's ResponseClasses from its observed
+// status distribution: 5xx always counts as a failure, and 4xx is folded in
+// too once it crosses fourXXThreshold of total traffic.
+func (s *routeStats) responseClasses(fourXXThreshold float64) []*sp.ResponseClass {
+	if s == nil || s.total() == 0 {
+		return nil
+	}
+
+	classes := []*sp.ResponseClass{
+		{
+			Condition: &sp.ResponseMatch{Status: &sp.Range{Min: 500, Max: 599}},
+			IsFailure: true,
+		},
+	}
+
+	total := s.total()
+	var clientErrors int
+	for status, count := range s.statusCounts {
+		if status >= 400 && status < 500 {
+			clientErrors += count
+		}
+	}
+	if float64(clientErrors)/float64(total) > fourXXThreshold {
+		classes = append(classes, &sp.ResponseClass{
+			Condition: &sp.ResponseMatch{Status: &sp.Range{Min: 400, Max: 499}},
+			IsFailure: true,
+		})
+	}
+
+	return classes
+}
+
+// ceilDuration rounds d up to the next multiple of unit.
+func ceilDuration(d, unit time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	if rem := d % unit; rem != 0 {
+		return d + unit - rem
+	}
+	return d
+}
+
+// roundTimeout rounds a latency up to a sane, human-readable timeout: whole
+// tens of milliseconds below a second, otherwise whole seconds.
+func roundTimeout(d time.Duration) time.Duration {
+	if d < time.Second {
+		return ceilDuration(d, 10*time.Millisecond)
+	}
+	return ceilDuration(d, time.Second)
+}
+
+// decorateRoute fills in Timeout, ResponseClasses and IsRetryable on route
+// from the accumulated stats, honoring the inference options requested on
+// the command line. A nil or empty stats leaves the route untouched.
+func decorateRoute(route *sp.RouteSpec, stats *routeStats, opts TapProfileOptions) {
+	if stats == nil || stats.total() == 0 {
+		return
+	}
+
+	if opts.InferTimeouts {
+		percentile := opts.FailurePercentile
+		if percentile <= 0 {
+			percentile = defaultFailurePercentile
+		}
+		if latency := stats.percentileLatency(percentile); latency > 0 {
+			route.Timeout = roundTimeout(latency).String()
+		}
+	}
+
+	route.ResponseClasses = stats.responseClasses(opts.fourXXFailureRatio())
+
+	if opts.InferRetries && stats.failureRatio(opts.fourXXFailureRatio()) >= nonTrivialFailureRatio {
+		route.IsRetryable = true
+	}
+}
+
+// inferRetryBudget returns a profile-wide RetryBudget when at least one
+// route's failure ratio (at the given 4xx threshold) is non-trivial, since
+// RetryBudget is configured once per ServiceProfile rather than per route.
+func inferRetryBudget(allStats []*routeStats, fourXXThreshold float64) *sp.RetryBudget {
+	for _, stats := range allStats {
+		if stats != nil && stats.total() > 0 && stats.failureRatio(fourXXThreshold) >= nonTrivialFailureRatio {
+			return &sp.RetryBudget{
+				RetryRatio:          0.2,
+				MinRetriesPerSecond: 10,
+				TTL:                 "10s",
+			}
+		}
+	}
+	return nil
+}
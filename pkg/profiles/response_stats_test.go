@@ -0,0 +1,112 @@
+package profiles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouteStatsFailureRatio(t *testing.T) {
+	stats := newRouteStats()
+	for i := 0; i < 95; i++ {
+		stats.recordStatus(200)
+	}
+	for i := 0; i < 5; i++ {
+		stats.recordStatus(503)
+	}
+
+	if got := stats.failureRatio(fourXXFailureRatio); got != 0.05 {
+		t.Errorf("expected failure ratio 0.05, got %v", got)
+	}
+}
+
+func TestRouteStatsFailureRatioHonorsConfiguredFourXXThreshold(t *testing.T) {
+	stats := newRouteStats()
+	for i := 0; i < 80; i++ {
+		stats.recordStatus(200)
+	}
+	for i := 0; i < 20; i++ {
+		stats.recordStatus(404)
+	}
+
+	// 20% 4xx is above the default 10% threshold but below a configured 50%.
+	if got := stats.failureRatio(fourXXFailureRatio); got != 0.2 {
+		t.Errorf("expected default threshold to fold in the 4xxs, got %v", got)
+	}
+	if got := stats.failureRatio(0.5); got != 0 {
+		t.Errorf("expected a 50%% threshold to exclude the 4xxs, got %v", got)
+	}
+}
+
+func TestRouteStatsPercentileLatency(t *testing.T) {
+	stats := newRouteStats()
+	for i := 1; i <= 100; i++ {
+		stats.recordLatency(time.Duration(i) * time.Millisecond)
+	}
+
+	if got := stats.percentileLatency(0.99); got != 99*time.Millisecond {
+		t.Errorf("expected p99 of 99ms, got %v", got)
+	}
+}
+
+func TestRoundTimeout(t *testing.T) {
+	testCases := []struct {
+		in   time.Duration
+		want time.Duration
+	}{
+		{123 * time.Millisecond, 130 * time.Millisecond},
+		{900 * time.Millisecond, 900 * time.Millisecond},
+		{1100 * time.Millisecond, 2 * time.Second},
+		{3 * time.Second, 3 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		if got := roundTimeout(tc.in); got != tc.want {
+			t.Errorf("roundTimeout(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestDecorateRouteMarksFailingRouteRetryable(t *testing.T) {
+	stats := newRouteStats()
+	for i := 0; i < 90; i++ {
+		stats.recordStatus(200)
+	}
+	for i := 0; i < 10; i++ {
+		stats.recordStatus(500)
+	}
+	stats.recordLatency(100 * time.Millisecond)
+
+	route := mkRouteSpec("/widgets", "^/widgets$", "GET", nil)
+	decorateRoute(route, stats, TapProfileOptions{InferTimeouts: true, InferRetries: true})
+
+	if !route.IsRetryable {
+		t.Error("expected route to be marked retryable")
+	}
+	if route.Timeout == "" {
+		t.Error("expected a non-empty inferred timeout")
+	}
+	if len(route.ResponseClasses) != 1 {
+		t.Fatalf("expected a single 5xx response class, got %d", len(route.ResponseClasses))
+	}
+}
+
+func TestInferRetryBudgetRequiresNonTrivialFailures(t *testing.T) {
+	healthy := newRouteStats()
+	for i := 0; i < 100; i++ {
+		healthy.recordStatus(200)
+	}
+	if budget := inferRetryBudget([]*routeStats{healthy}, fourXXFailureRatio); budget != nil {
+		t.Errorf("expected no retry budget for an all-2xx route, got %v", budget)
+	}
+
+	failing := newRouteStats()
+	for i := 0; i < 90; i++ {
+		failing.recordStatus(200)
+	}
+	for i := 0; i < 10; i++ {
+		failing.recordStatus(500)
+	}
+	if budget := inferRetryBudget([]*routeStats{healthy, failing}, fourXXFailureRatio); budget == nil {
+		t.Error("expected a retry budget when one route has a non-trivial failure ratio")
+	}
+}
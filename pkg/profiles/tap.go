@@ -23,10 +23,75 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// TapDirection selects which side of the proxy's traffic a tap-derived
+// profile should describe.
+type TapDirection string
+
+const (
+	// DirectionInbound considers only traffic the tapped resource receives.
+	DirectionInbound TapDirection = "inbound"
+	// DirectionOutbound considers only traffic the tapped resource sends,
+	// i.e. what it actually calls on its downstream services.
+	DirectionOutbound TapDirection = "outbound"
+	// DirectionBoth considers both, producing one profile per direction.
+	DirectionBoth TapDirection = "both"
+)
+
+// direction returns the configured direction, defaulting to DirectionInbound
+// to preserve the historical behavior of this package.
+func (o TapProfileOptions) direction() TapDirection {
+	if o.Direction == "" {
+		return DirectionInbound
+	}
+	return o.Direction
+}
+
+// fourXXFailureRatio returns the configured --fourxx-failure-threshold,
+// defaulting to fourXXFailureRatio if unset.
+func (o TapProfileOptions) fourXXFailureRatio() float64 {
+	if o.FourXXFailureRatio <= 0 {
+		return fourXXFailureRatio
+	}
+	return o.FourXXFailureRatio
+}
+
+// TapProfileOptions bundles the `linkerd profile --tap` flags that shape how
+// the tapped traffic is turned into a ServiceProfile. It exists because that
+// flag set has grown past what's comfortable as positional parameters.
+type TapProfileOptions struct {
+	// TapDuration is how long to tap before generating the profile.
+	TapDuration time.Duration
+	// RouteLimit caps the number of distinct routes (pre-consolidation
+	// observations) collected before cutting the tap short.
+	RouteLimit int
+	// ConsolidatePaths enables the `--path-template`/`--consolidate` pass
+	// that groups structurally similar paths into templated routes.
+	ConsolidatePaths bool
+	// InferTimeouts enables `--infer-timeouts`: derive each route's Timeout
+	// from its observed latency distribution.
+	InferTimeouts bool
+	// InferRetries enables `--infer-retries`: mark routes with a non-trivial
+	// failure ratio as retryable, and suggest a profile-wide RetryBudget.
+	InferRetries bool
+	// FailurePercentile is the latency percentile (0, 1] used to derive a
+	// route's suggested Timeout. Defaults to defaultFailurePercentile.
+	FailurePercentile float64
+	// Direction selects inbound, outbound, or both. Defaults to
+	// DirectionInbound.
+	Direction TapDirection
+	// FourXXFailureRatio is the minimum share of 4xx responses, of all
+	// responses observed on a route, before those statuses are folded into
+	// the route's ResponseClasses (and failureRatio/RetryBudget inference)
+	// as failures too. Defaults to fourXXFailureRatio if unset.
+	FourXXFailureRatio float64
+}
+
 // RenderTapOutputProfile performs a tap on the desired resource and generates
-// a service profile with routes pre-populated from the tap data
-// Only inbound tap traffic is considered.
-func RenderTapOutputProfile(ctx context.Context, k8sAPI *k8s.KubernetesAPI, tapResource, namespace, name, clusterDomain string, tapDuration time.Duration, routeLimit int, w io.Writer) error {
+// a service profile with routes pre-populated from the tap data. By default
+// only inbound tap traffic is considered; set opts.Direction to describe the
+// tapped resource's outbound calls instead, or both directions at once, in
+// which case two YAML documents (separated by "---") are written to w.
+func RenderTapOutputProfile(ctx context.Context, k8sAPI *k8s.KubernetesAPI, tapResource, namespace, name, clusterDomain string, opts TapProfileOptions, w io.Writer) error {
 	requestParams := util.TapRequestParams{
 		Resource:  tapResource,
 		Namespace: namespace,
@@ -38,46 +103,230 @@ func RenderTapOutputProfile(ctx context.Context, k8sAPI *k8s.KubernetesAPI, tapR
 		return err
 	}
 
-	profile, err := tapToServiceProfile(ctx, k8sAPI, req, namespace, name, clusterDomain, tapDuration, routeLimit)
+	profiles, err := tapToServiceProfiles(ctx, k8sAPI, req, namespace, name, clusterDomain, opts)
 	if err != nil {
 		return err
 	}
 
-	output, err := yaml.Marshal(profile)
-	if err != nil {
-		return fmt.Errorf("Error writing Service Profile: %s", err)
-	}
-	w.Write(output)
-	return nil
+	return writeServiceProfiles(profiles, w)
 }
 
-func tapToServiceProfile(ctx context.Context, k8sAPI *k8s.KubernetesAPI, tapReq *pb.TapByResourceRequest, namespace, name, clusterDomain string, tapDuration time.Duration, routeLimit int) (sp.ServiceProfile, error) {
-	profile := sp.ServiceProfile{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s.%s.svc.%s", name, namespace, clusterDomain),
-			Namespace: namespace,
-		},
-		TypeMeta: serviceProfileMeta,
+// writeServiceProfiles marshals each profile as YAML to w, separating
+// multiple profiles (as produced by opts.Direction == DirectionBoth) into a
+// "---"-delimited multi-document stream.
+func writeServiceProfiles(profiles []sp.ServiceProfile, w io.Writer) error {
+	for i, profile := range profiles {
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		output, err := yaml.Marshal(profile)
+		if err != nil {
+			return fmt.Errorf("Error writing Service Profile: %s", err)
+		}
+		w.Write(output)
 	}
+	return nil
+}
 
-	ctxWithTime, cancel := context.WithTimeout(ctx, tapDuration)
+// tapToServiceProfiles runs the tap and returns one ServiceProfile per
+// requested direction, in (inbound, outbound) order.
+func tapToServiceProfiles(ctx context.Context, k8sAPI *k8s.KubernetesAPI, tapReq *pb.TapByResourceRequest, namespace, name, clusterDomain string, opts TapProfileOptions) ([]sp.ServiceProfile, error) {
+	ctxWithTime, cancel := context.WithTimeout(ctx, opts.TapDuration)
 	defer cancel()
 	reader, body, err := tap.Reader(ctxWithTime, k8sAPI, tapReq)
 	if err != nil {
-		return profile, err
+		return nil, err
 	}
 	defer body.Close()
 
-	routes := routeSpecFromTap(reader, routeLimit)
+	result := collectTapData(reader, opts)
+	return profilesFromTapResult(result, namespace, name, clusterDomain, opts), nil
+}
+
+// profilesFromTapResult turns an already-collected tapCollectionResult into
+// one ServiceProfile per requested direction. Factored out of
+// tapToServiceProfiles so this (and not the tap-stream I/O above it) is what
+// gets exercised directly in tests.
+func profilesFromTapResult(result tapCollectionResult, namespace, name, clusterDomain string, opts TapProfileOptions) []sp.ServiceProfile {
+	var directions []TapDirection
+	switch opts.direction() {
+	case DirectionBoth:
+		directions = []TapDirection{DirectionInbound, DirectionOutbound}
+	default:
+		directions = []TapDirection{opts.direction()}
+	}
+
+	profiles := make([]sp.ServiceProfile, 0, len(directions))
+	for _, dir := range directions {
+		profileName := fmt.Sprintf("%s.%s.svc.%s", name, namespace, clusterDomain)
+		profileNamespace := namespace
+		// For outbound profiles, name the profile after the actual
+		// destination the tapped resource called, not the tapped resource's
+		// own identity, since that's what the profile describes.
+		if dir == DirectionOutbound {
+			if dest, ok := result.destinations[dir]; ok {
+				destName, destNamespace := dest[0], dest[1]
+				profileName = fmt.Sprintf("%s.%s.svc.%s", destName, destNamespace, clusterDomain)
+				profileNamespace = destNamespace
+			}
+		}
+
+		profiles = append(profiles, sp.ServiceProfile{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      profileName,
+				Namespace: profileNamespace,
+			},
+			TypeMeta: serviceProfileMeta,
+			Spec: sp.ServiceProfileSpec{
+				Routes:      result.routes[dir],
+				RetryBudget: result.retryBudgets[dir],
+			},
+		})
+	}
+
+	return profiles
+}
+
+// pendingRequest tracks an in-flight request between its RequestInit and
+// ResponseEnd tap events, keyed by the stream id tap assigns to it.
+type pendingRequest struct {
+	direction TapDirection
+	method    string
+	path      string
+	status    uint32
+}
+
+type streamKey struct {
+	base   uint32
+	stream uint64
+}
+
+// tapCollectionResult holds everything gathered from a tap stream, bucketed
+// per direction so that --direction both can emit two independent profiles
+// from a single tap session.
+type tapCollectionResult struct {
+	routes       map[TapDirection][]*sp.RouteSpec
+	retryBudgets map[TapDirection]*sp.RetryBudget
+	// destinations holds the first outbound destination service/namespace
+	// observed for each direction (only ever populated for DirectionOutbound).
+	destinations map[TapDirection][2]string
+}
 
-	profile.Spec.Routes = routes
+// tapAccumulator holds the mutable state collectTapData threads across a tap
+// stream. Factored out so the per-event and final-aggregation logic can be
+// exercised directly in tests without needing to fake the tap wire format.
+type tapAccumulator struct {
+	routesMap    map[TapDirection]map[string]*sp.RouteSpec
+	observations map[TapDirection]map[string][]string
+	statsByPath  map[TapDirection]map[string]*routeStats
+	destinations map[TapDirection][2]string
+	pending      map[streamKey]*pendingRequest
+	count        int
+}
 
-	return profile, nil
+func newTapAccumulator() *tapAccumulator {
+	return &tapAccumulator{
+		routesMap: map[TapDirection]map[string]*sp.RouteSpec{
+			DirectionInbound:  {},
+			DirectionOutbound: {},
+		},
+		observations: map[TapDirection]map[string][]string{
+			DirectionInbound:  {},
+			DirectionOutbound: {},
+		},
+		statsByPath: map[TapDirection]map[string]*routeStats{
+			DirectionInbound:  {},
+			DirectionOutbound: {},
+		},
+		destinations: make(map[TapDirection][2]string),
+		pending:      make(map[streamKey]*pendingRequest),
+	}
 }
 
-func routeSpecFromTap(tapByteStream *bufio.Reader, routeLimit int) []*sp.RouteSpec {
-	routes := make([]*sp.RouteSpec, 0)
-	routesMap := make(map[string]*sp.RouteSpec)
+// processTapEvent folds one tap event into the accumulator. It returns false
+// once opts.RouteLimit has been reached, signaling the caller to stop
+// reading further events.
+func (a *tapAccumulator) processTapEvent(event *pb.TapEvent, opts TapProfileOptions) bool {
+	recordResponseData(event, a.pending, a.statsByPath)
+
+	dir, method, path, ok := pathAndMethodFromTap(event, opts)
+	if !ok {
+		return true
+	}
+
+	if dir == DirectionOutbound {
+		if _, seen := a.destinations[dir]; !seen {
+			if destName, destNamespace, ok := destinationFromTap(event); ok {
+				a.destinations[dir] = [2]string{destName, destNamespace}
+			}
+		}
+	}
+
+	if opts.ConsolidatePaths {
+		trackPending(event, dir, method, path, a.pending)
+		seen := false
+		for _, p := range a.observations[dir][method] {
+			if p == path {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			a.observations[dir][method] = append(a.observations[dir][method], path)
+			a.count++
+		}
+	} else {
+		route := mkRouteSpec(path, pathToRegex(path), method, nil)
+		trackPending(event, dir, method, path, a.pending)
+		if _, seen := a.routesMap[dir][route.Name]; !seen {
+			a.count++
+		}
+		a.routesMap[dir][route.Name] = route
+	}
+
+	return a.count < opts.RouteLimit
+}
+
+// result aggregates everything folded in so far into a tapCollectionResult,
+// applying path consolidation (and the response-stats-derived decorations)
+// per direction.
+func (a *tapAccumulator) result(opts TapProfileOptions) tapCollectionResult {
+	result := tapCollectionResult{
+		routes:       make(map[TapDirection][]*sp.RouteSpec),
+		retryBudgets: make(map[TapDirection]*sp.RetryBudget),
+		destinations: a.destinations,
+	}
+
+	for _, dir := range []TapDirection{DirectionInbound, DirectionOutbound} {
+		if opts.ConsolidatePaths {
+			routes, allStats := consolidatePaths(a.observations[dir], a.statsByPath[dir], opts)
+			result.routes[dir] = routes
+			if opts.InferRetries {
+				result.retryBudgets[dir] = inferRetryBudget(allStats, opts.fourXXFailureRatio())
+			}
+			continue
+		}
+
+		routes := make([]*sp.RouteSpec, 0, len(a.routesMap[dir]))
+		allStats := make([]*routeStats, 0, len(a.routesMap[dir]))
+		for _, path := range sortMapKeys(a.routesMap[dir]) {
+			route := a.routesMap[dir][path]
+			stats := a.statsByPath[dir][path]
+			decorateRoute(route, stats, opts)
+			allStats = append(allStats, stats)
+			routes = append(routes, route)
+		}
+		result.routes[dir] = routes
+		if opts.InferRetries {
+			result.retryBudgets[dir] = inferRetryBudget(allStats, opts.fourXXFailureRatio())
+		}
+	}
+
+	return result
+}
+
+func collectTapData(tapByteStream *bufio.Reader, opts TapProfileOptions) tapCollectionResult {
+	acc := newTapAccumulator()
 
 	for {
 		log.Debug("Waiting for data...")
@@ -95,21 +344,73 @@ func routeSpecFromTap(tapByteStream *bufio.Reader, routeLimit int) []*sp.RouteSp
 			break
 		}
 
-		routeSpec := getPathDataFromTap(&event)
-		log.Debugf("Created route spec: %v", routeSpec)
+		if !acc.processTapEvent(&event, opts) {
+			break
+		}
+	}
 
-		if routeSpec != nil {
-			routesMap[routeSpec.Name] = routeSpec
-			if len(routesMap) >= routeLimit {
-				break
-			}
+	return acc.result(opts)
+}
+
+// trackPending records that the given stream id now corresponds to an
+// in-flight (method, path) request, so that a later ResponseInit/ResponseEnd
+// for the same stream can be attributed to the right route.
+func trackPending(event *pb.TapEvent, direction TapDirection, method, path string, pending map[streamKey]*pendingRequest) {
+	id := requestStreamID(event)
+	if id == nil || method == "" || path == "" {
+		return
+	}
+	pending[streamKey{id.GetBase(), id.GetStream()}] = &pendingRequest{direction: direction, method: method, path: path}
+}
+
+// recordResponseData watches for ResponseInit (to capture the HTTP status)
+// and ResponseEnd (to capture end-to-end latency and flush the sample into
+// statsByPath) events, correlating them back to the request they belong to
+// via trackPending's stream id.
+func recordResponseData(event *pb.TapEvent, pending map[streamKey]*pendingRequest, statsByPath map[TapDirection]map[string]*routeStats) {
+	switch ev := event.GetHttp().GetEvent().(type) {
+	case *pb.TapEvent_Http_ResponseInit_:
+		id := ev.ResponseInit.GetId()
+		if id == nil {
+			return
+		}
+		if req, ok := pending[streamKey{id.GetBase(), id.GetStream()}]; ok {
+			req.status = ev.ResponseInit.GetHttpStatus()
+		}
+	case *pb.TapEvent_Http_ResponseEnd_:
+		id := ev.ResponseEnd.GetId()
+		if id == nil {
+			return
+		}
+		key := streamKey{id.GetBase(), id.GetStream()}
+		req, ok := pending[key]
+		if !ok {
+			return
+		}
+		delete(pending, key)
+
+		byPath := statsByPath[req.direction]
+		stats, ok := byPath[req.path]
+		if !ok {
+			stats = newRouteStats()
+			byPath[req.path] = stats
+		}
+		if req.status != 0 {
+			stats.recordStatus(req.status)
+		}
+		if latency := ev.ResponseEnd.GetSinceRequestInit(); latency != nil {
+			stats.recordLatency(latency.AsDuration())
 		}
 	}
+}
 
-	for _, path := range sortMapKeys(routesMap) {
-		routes = append(routes, routesMap[path])
+// requestStreamID returns the tap stream id for a RequestInit event, or nil
+// for any other event type.
+func requestStreamID(event *pb.TapEvent) *pb.TapEvent_Http_StreamId {
+	if ev, ok := event.GetHttp().GetEvent().(*pb.TapEvent_Http_RequestInit_); ok {
+		return ev.RequestInit.GetId()
 	}
-	return routes
+	return nil
 }
 
 func sortMapKeys(m map[string]*sp.RouteSpec) (keys []string) {
@@ -120,24 +421,45 @@ func sortMapKeys(m map[string]*sp.RouteSpec) (keys []string) {
 	return
 }
 
-func getPathDataFromTap(event *pb.TapEvent) *sp.RouteSpec {
-	if event.GetProxyDirection() != pb.TapEvent_INBOUND {
-		return nil
+// destinationFromTap reads the service and namespace the tapped event was
+// actually destined for, used to name an outbound profile after the real
+// downstream service rather than the tapped resource's own identity.
+func destinationFromTap(event *pb.TapEvent) (service string, namespace string, ok bool) {
+	labels := event.GetDestination().GetLabels()
+	service, hasService := labels["service"]
+	namespace, hasNamespace := labels["namespace"]
+	return service, namespace, hasService && hasNamespace
+}
+
+// pathAndMethodFromTap extracts the request method and path from an HTTP
+// request-init tap event whose direction matches opts.direction(). It
+// returns ok=false for events on the wrong direction, non-HTTP events, and
+// the root path ("/"), which callers treat the same way this package always
+// has: not worth a route of its own.
+func pathAndMethodFromTap(event *pb.TapEvent, opts TapProfileOptions) (direction TapDirection, method string, path string, ok bool) {
+	var eventDirection TapDirection
+	switch event.GetProxyDirection() {
+	case pb.TapEvent_INBOUND:
+		eventDirection = DirectionInbound
+	case pb.TapEvent_OUTBOUND:
+		eventDirection = DirectionOutbound
+	default:
+		return "", "", "", false
+	}
+
+	wanted := opts.direction()
+	if wanted != DirectionBoth && eventDirection != wanted {
+		return "", "", "", false
 	}
 
 	switch ev := event.GetHttp().GetEvent().(type) {
 	case *pb.TapEvent_Http_RequestInit_:
 		path := ev.RequestInit.GetPath()
 		if path == "/" {
-			return nil
+			return "", "", "", false
 		}
-
-		return mkRouteSpec(
-			path,
-			pathToRegex(path), // for now, no path consolidation
-			ev.RequestInit.GetMethod().GetRegistered().String(),
-			nil)
+		return eventDirection, ev.RequestInit.GetMethod().GetRegistered().String(), path, true
 	default:
-		return nil
+		return "", "", "", false
 	}
 }
@@ -0,0 +1,176 @@
+package profiles
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	pb "github.com/linkerd/linkerd2/controller/gen/public"
+)
+
+func requestInitEvent(direction pb.TapEvent_ProxyDirection, path string) *pb.TapEvent {
+	return &pb.TapEvent{
+		ProxyDirection: direction,
+		Destination: &pb.TapEvent_EndpointMeta{
+			Labels: map[string]string{"service": "downstream", "namespace": "ns"},
+		},
+		Event: &pb.TapEvent_Http_{
+			Http: &pb.TapEvent_Http{
+				Event: &pb.TapEvent_Http_RequestInit_{
+					RequestInit: &pb.TapEvent_Http_RequestInit{
+						Path: path,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPathAndMethodFromTapDirectionFiltering(t *testing.T) {
+	inboundEvent := requestInitEvent(pb.TapEvent_INBOUND, "/in")
+	outboundEvent := requestInitEvent(pb.TapEvent_OUTBOUND, "/out")
+
+	testCases := []struct {
+		name      string
+		event     *pb.TapEvent
+		direction TapDirection
+		wantOK    bool
+	}{
+		{"inbound event, default (inbound) direction", inboundEvent, "", true},
+		{"outbound event, default (inbound) direction", outboundEvent, "", false},
+		{"outbound event, outbound direction", outboundEvent, DirectionOutbound, true},
+		{"inbound event, outbound direction", inboundEvent, DirectionOutbound, false},
+		{"inbound event, both", inboundEvent, DirectionBoth, true},
+		{"outbound event, both", outboundEvent, DirectionBoth, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, _, ok := pathAndMethodFromTap(tc.event, TapProfileOptions{Direction: tc.direction})
+			if ok != tc.wantOK {
+				t.Errorf("pathAndMethodFromTap() ok = %v, want %v", ok, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestDestinationFromTap(t *testing.T) {
+	event := requestInitEvent(pb.TapEvent_OUTBOUND, "/out")
+
+	service, namespace, ok := destinationFromTap(event)
+	if !ok || service != "downstream" || namespace != "ns" {
+		t.Errorf("destinationFromTap() = (%q, %q, %v), want (downstream, ns, true)", service, namespace, ok)
+	}
+
+	noLabels := &pb.TapEvent{}
+	if _, _, ok := destinationFromTap(noLabels); ok {
+		t.Error("expected destinationFromTap to fail without destination labels")
+	}
+}
+
+// httpRequestInitEvent, httpResponseInitEvent and httpResponseEndEvent build
+// the trio of tap events a single HTTP request/response produces on the
+// wire, so tapAccumulator can be exercised end to end without needing a real
+// tap byte stream.
+func httpRequestInitEvent(direction pb.TapEvent_ProxyDirection, path string, id *pb.TapEvent_Http_StreamId) *pb.TapEvent {
+	return &pb.TapEvent{
+		ProxyDirection: direction,
+		Destination: &pb.TapEvent_EndpointMeta{
+			Labels: map[string]string{"service": "downstream", "namespace": "ns"},
+		},
+		Event: &pb.TapEvent_Http_{
+			Http: &pb.TapEvent_Http{
+				Event: &pb.TapEvent_Http_RequestInit_{
+					RequestInit: &pb.TapEvent_Http_RequestInit{
+						Id:   id,
+						Path: path,
+					},
+				},
+			},
+		},
+	}
+}
+
+func httpResponseInitEvent(id *pb.TapEvent_Http_StreamId, status uint32) *pb.TapEvent {
+	return &pb.TapEvent{
+		Event: &pb.TapEvent_Http_{
+			Http: &pb.TapEvent_Http{
+				Event: &pb.TapEvent_Http_ResponseInit_{
+					ResponseInit: &pb.TapEvent_Http_ResponseInit{
+						Id:         id,
+						HttpStatus: status,
+					},
+				},
+			},
+		},
+	}
+}
+
+func httpResponseEndEvent(id *pb.TapEvent_Http_StreamId) *pb.TapEvent {
+	return &pb.TapEvent{
+		Event: &pb.TapEvent_Http_{
+			Http: &pb.TapEvent_Http{
+				Event: &pb.TapEvent_Http_ResponseEnd_{
+					ResponseEnd: &pb.TapEvent_Http_ResponseEnd{
+						Id: id,
+					},
+				},
+			},
+		},
+	}
+}
+
+// TestCollectTapDataBothDirectionsEndToEnd drives synthetic tap events for
+// both an inbound and an outbound request through the same accumulator
+// collectTapData uses, for opts.Direction == DirectionBoth. It exercises the
+// two claims unit tests elsewhere in this package can't reach in isolation:
+// that both directions produce their own profile, and that the outbound
+// profile is named after the tapped resource's actual destination rather
+// than its own identity.
+func TestCollectTapDataBothDirectionsEndToEnd(t *testing.T) {
+	inboundStream := &pb.TapEvent_Http_StreamId{Base: 1, Stream: 1}
+	outboundStream := &pb.TapEvent_Http_StreamId{Base: 2, Stream: 1}
+
+	opts := TapProfileOptions{Direction: DirectionBoth, RouteLimit: 100}
+	acc := newTapAccumulator()
+	for _, event := range []*pb.TapEvent{
+		httpRequestInitEvent(pb.TapEvent_INBOUND, "/widgets", inboundStream),
+		httpResponseInitEvent(inboundStream, 200),
+		httpResponseEndEvent(inboundStream),
+		httpRequestInitEvent(pb.TapEvent_OUTBOUND, "/api", outboundStream),
+		httpResponseInitEvent(outboundStream, 200),
+		httpResponseEndEvent(outboundStream),
+	} {
+		if !acc.processTapEvent(event, opts) {
+			break
+		}
+	}
+
+	profiles := profilesFromTapResult(acc.result(opts), "svcns", "web", "cluster.local", opts)
+	if len(profiles) != 2 {
+		t.Fatalf("expected 2 profiles (inbound, outbound), got %d", len(profiles))
+	}
+	inbound, outbound := profiles[0], profiles[1]
+
+	if inbound.ObjectMeta.Name != "web.svcns.svc.cluster.local" || inbound.ObjectMeta.Namespace != "svcns" {
+		t.Errorf("expected the inbound profile named after the tapped resource itself, got %s/%s", inbound.ObjectMeta.Namespace, inbound.ObjectMeta.Name)
+	}
+	if names := routeNames(inbound.Spec.Routes); len(names) != 1 || names[0] != "/widgets" {
+		t.Errorf("expected the inbound profile to have a /widgets route, got %v", names)
+	}
+
+	if outbound.ObjectMeta.Name != "downstream.ns.svc.cluster.local" || outbound.ObjectMeta.Namespace != "ns" {
+		t.Errorf("expected the outbound profile named after the tap destination (downstream.ns), got %s/%s", outbound.ObjectMeta.Namespace, outbound.ObjectMeta.Name)
+	}
+	if names := routeNames(outbound.Spec.Routes); len(names) != 1 || names[0] != "/api" {
+		t.Errorf("expected the outbound profile to have an /api route, got %v", names)
+	}
+
+	var buf bytes.Buffer
+	if err := writeServiceProfiles(profiles, &buf); err != nil {
+		t.Fatalf("unexpected error writing profiles: %v", err)
+	}
+	if got := strings.Count(buf.String(), "---"); got != 1 {
+		t.Errorf("expected a single \"---\" separator between the two profiles in the written stream, got %d:\n%s", got, buf.String())
+	}
+}